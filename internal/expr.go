@@ -3,14 +3,31 @@ package internal
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
-// ParseExpr parses the provided string into an Expr, returning an error in case of poor syntax.
+// ParseExpr parses the provided string into an Expr, returning an error in case of poor syntax. str must begin with
+// "=", matching the notation cells are set with; use ParseExprRaw to parse an expression without a leading "=".
 func ParseExpr(str string) (Expr, error) {
 	tokens, err := tokenize(str)
 	if err != nil {
 		return nil, err
 	}
+	return parseTokens(tokens)
+}
+
+// ParseExprRaw parses str as an expression, same as ParseExpr, except str must not have a leading "=". This is the
+// inverse of Expr.String(), which also omits the leading "=".
+func ParseExprRaw(str string) (Expr, error) {
+	tokens, err := tokenizeRaw(str)
+	if err != nil {
+		return nil, err
+	}
+	return parseTokens(tokens)
+}
+
+// parseTokens parses a complete expression out of tokens, failing if any tokens are left over.
+func parseTokens(tokens []Token) (Expr, error) {
 	expr, rest, err := parseExpr(tokens)
 	if err != nil {
 		return nil, err
@@ -28,30 +45,55 @@ var runeMap = map[rune]Token{
 	'/': TokenDiv,
 	'(': TokenLPar,
 	')': TokenRPar,
+	',': TokenComma,
+	':': TokenColon,
 }
 
+// comparisonRunes are the runes which may begin a (possibly two-character) comparison token.
+var comparisonRunes = map[rune]struct{}{'>': {}, '<': {}, '=': {}, '!': {}}
+
 // tokenize tokenizes the provided expression into a list of tokens, returning a ErrExprParse if any unexpected
-// characters are found.
+// characters are found. str must begin with "=".
 func tokenize(str string) ([]Token, error) {
 	runes := []rune(str)
-	if runes[0] != '=' {
+	if len(runes) == 0 || runes[0] != '=' {
 		return nil, fmt.Errorf("%w: expressions must start with =", ErrExprParse)
 	}
+	return tokenizeRaw(string(runes[1:]))
+}
+
+// tokenizeRaw tokenizes str into a list of tokens, same as tokenize, except str must not have a leading "=".
+func tokenizeRaw(str string) ([]Token, error) {
+	runes := []rune(str)
 	var tokens []Token
-	for i := 1; i < len(runes); i++ {
-		for runes[i] == ' ' { // skip whitespace
+	for i := 0; i < len(runes); i++ {
+		for i < len(runes) && runes[i] == ' ' { // skip whitespace
 			i++
 		}
-		if between(runes[i], '0', '9') {
-			// tokenize constant integer expression
+		if i >= len(runes) {
+			break
+		}
+		if runes[i] == '"' {
+			// tokenize a quoted string literal, keeping the surrounding quotes so parsePrimary can recognize it.
 			start := i
-			for i < len(runes) && between(runes[i], '0', '9') {
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string literal", ErrExprParse)
+			}
+			tokens = append(tokens, Token(runes[start:i+1]))
+		} else if between(runes[i], '0', '9') {
+			// tokenize a constant integer or decimal expression
+			start := i
+			for i < len(runes) && (between(runes[i], '0', '9') || runes[i] == '.') {
 				i++
 			}
 			tokens = append(tokens, Token(runes[start:i]))
 			i--
 		} else if between(runes[i], 'A', 'Z') {
-			// tokenize cell reference
+			// tokenize cell reference or function name
 			start := i
 			for i < len(runes) && (between(runes[i], '0', '9') || between(runes[i], 'A', 'Z')) {
 				i++
@@ -59,6 +101,17 @@ func tokenize(str string) ([]Token, error) {
 			tokens = append(tokens, Token(runes[start:i]))
 			i--
 
+		} else if _, ok := comparisonRunes[runes[i]]; ok {
+			// tokenize comparison operators, which may be one or two characters long (e.g. '>', '>=')
+			if runes[i] != '>' && runes[i] != '<' && (i+1 >= len(runes) || runes[i+1] != '=') {
+				return nil, fmt.Errorf("%w: unexpected character '%c'", ErrExprParse, runes[i])
+			}
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, Token(string(runes[i])+"="))
+				i++
+			} else {
+				tokens = append(tokens, Token(runes[i]))
+			}
 		} else if token, ok := runeMap[runes[i]]; ok {
 			tokens = append(tokens, token)
 		} else {
@@ -75,7 +128,13 @@ func between(target rune, lb, ub rune) bool {
 
 // parseExpr parses out an entire expression.
 func parseExpr(tokens []Token) (Expr, []Token, error) {
-	return parseTerm(tokens)
+	return parseComparison(tokens)
+}
+
+// parseComparison parses out comparisons (>, <, >=, <=, ==, !=), which bind more loosely than + and -.
+func parseComparison(tokens []Token) (Expr, []Token, error) {
+	var comparisonTokens = map[Token]struct{}{TokenGT: {}, TokenLT: {}, TokenGE: {}, TokenLE: {}, TokenEQ: {}, TokenNE: {}}
+	return parseBinExpr(tokens, comparisonTokens, parseTerm)
 }
 
 // parseTerm parses out addition and subtraction.
@@ -102,7 +161,6 @@ func parseBinExpr(tokens []Token, validOps map[Token]struct{}, next func([]Token
 	if len(rest) == 0 {
 		return expr, nil, err
 	}
-	fmt.Println("rest: ", rest)
 	// continue parsing out as many factor expressions as possible
 	token := rest[0]
 	_, ok := validOps[token]
@@ -131,24 +189,38 @@ func parseUnary(tokens []Token) (Expr, []Token, error) {
 		if err != nil {
 			return nil, nil, err
 		}
-		if X, ok := X.(ConstExpr); ok { // small optimization to shorten the tree
-			return ConstExpr{Value: -X.Value}, rest, nil
-		}
 		return UnaryExpr{X: X, Op: TokenSub}, rest, nil
 	}
 	return parsePrimary(tokens)
 }
 
-// parsePrimary parses out primary expressions, terms, parenthesized terms, etc.
+// parsePrimary parses out primary expressions, terms, parenthesized terms, function calls, and ranges.
 func parsePrimary(tokens []Token) (Expr, []Token, error) {
 	if len(tokens) == 0 {
 		return nil, nil, fmt.Errorf("%w: expected terms; found nothing", ErrExprParse)
 	}
+	if tok := string(tokens[0]); len(tok) >= 2 && tok[0] == '"' && tok[len(tok)-1] == '"' {
+		return ConstExpr{Value: StringVal{Value: tok[1 : len(tok)-1]}}, tokens[1:], nil
+	}
 	if cellID, err := ParseCellID(string(tokens[0])); err == nil {
-		return CellRefExpr{Ref: cellID}, tokens[1:], nil
+		rest := tokens[1:]
+		if len(rest) > 0 && rest[0] == TokenColon {
+			if len(rest) < 2 {
+				return nil, nil, fmt.Errorf("%w: expected cell reference after ':'", ErrExprParse)
+			}
+			toID, err := ParseCellID(string(rest[1]))
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: expected cell reference after ':'", ErrExprParse)
+			}
+			return RangeExpr{From: cellID, To: toID}, rest[2:], nil
+		}
+		return CellRefExpr{Ref: cellID}, rest, nil
 	}
 	if val, err := strconv.Atoi(string(tokens[0])); err == nil {
-		return ConstExpr{Value: val}, tokens[1:], nil
+		return ConstExpr{Value: IntVal{Value: val}}, tokens[1:], nil
+	}
+	if val, err := strconv.ParseFloat(string(tokens[0]), 64); err == nil {
+		return ConstExpr{Value: FloatVal{Value: val}}, tokens[1:], nil
 	}
 	if tokens[0] == TokenLPar {
 		expr, rest, err := parseExpr(tokens[1:])
@@ -160,14 +232,64 @@ func parsePrimary(tokens []Token) (Expr, []Token, error) {
 		}
 		return expr, rest[1:], nil
 	}
+	if isIdentToken(tokens[0]) && len(tokens) > 1 && tokens[1] == TokenLPar {
+		return parseCall(tokens)
+	}
 	return nil, nil, fmt.Errorf("%w: unexpected token: %s", ErrExprParse, tokens[0])
 }
 
+// isIdentToken is true iff tok consists only of uppercase letters, making it a valid function name.
+func isIdentToken(tok Token) bool {
+	if len(tok) == 0 {
+		return false
+	}
+	for _, ch := range string(tok) {
+		if !between(ch, 'A', 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCall parses a function call of the form NAME(arg1, arg2, ...). tokens[0] must be the function name and
+// tokens[1] must be TokenLPar.
+func parseCall(tokens []Token) (Expr, []Token, error) {
+	name := string(tokens[0])
+	rest := tokens[2:]
+	if len(rest) > 0 && rest[0] == TokenRPar {
+		return CallExpr{Name: name}, rest[1:], nil
+	}
+	var args []Expr
+	for {
+		arg, r, err := parseExpr(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, arg)
+		rest = r
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("%w: expected ')'", ErrExprParse)
+		}
+		if rest[0] != TokenComma {
+			break
+		}
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || rest[0] != TokenRPar {
+		return nil, nil, fmt.Errorf("%w: expected ')'", ErrExprParse)
+	}
+	return CallExpr{Name: name, Args: args}, rest[1:], nil
+}
+
 // the model used here for representing parse trees is inspired by the ast package in Go's standard library.
 
 // Expr is an interface describing an expression.
 type Expr interface {
 	IsExpr() // marker method, just for type-safety.
+
+	// String renders the expression back into formula notation, using the minimum parentheses necessary to
+	// preserve its meaning. It omits the leading "="; ParseExprRaw(e.String()) reconstructs e.
+	String() string
 }
 
 type UnaryExpr struct {
@@ -183,9 +305,9 @@ type BinaryExpr struct {
 	Y  Expr  // right operand
 }
 
-// ConstExpr represents a constant valued expression.
+// ConstExpr represents a constant valued expression, e.g. 12, 3.14, or "hello".
 type ConstExpr struct {
-	Value int
+	Value Value
 }
 
 // CellRefExpr represents a variable reference to another cell.
@@ -193,23 +315,151 @@ type CellRefExpr struct {
 	Ref CellID
 }
 
+// RangeExpr represents an inclusive, rectangular range of cells, e.g. A1:B10. A RangeExpr is usually an argument to a
+// CallExpr; a bare range outside of a call evaluates to the sum of its cells. CellRefs expands it to its member
+// CellIDs, which Spreadsheet.rewire uses when building the dependency graph.
+type RangeExpr struct {
+	From CellID
+	To   CellID
+}
+
+// CallExpr represents a call to a named function, e.g. SUM(A1:A10). Functions are resolved at eval time against the
+// Spreadsheet's function registry; see Spreadsheet.RegisterFunc.
+type CallExpr struct {
+	Name string
+	Args []Expr
+}
+
 func (b ConstExpr) IsExpr()   {}
 func (u UnaryExpr) IsExpr()   {}
 func (b BinaryExpr) IsExpr()  {}
 func (b CellRefExpr) IsExpr() {}
+func (r RangeExpr) IsExpr()   {}
+func (c CallExpr) IsExpr()    {}
+
+// precedence levels used by String() to decide where parentheses are required, matching the grammar of parseExpr:
+// comparisons bind loosest, then +/-, then */, then unary minus; everything else is atomic.
+const (
+	precComparison = iota + 1
+	precTerm
+	precFactor
+	precUnary
+	precAtom
+)
+
+// precedence reports the binding strength of e's outermost operator, for use by String().
+func precedence(e Expr) int {
+	switch e := e.(type) {
+	case BinaryExpr:
+		switch e.Op {
+		case TokenGT, TokenLT, TokenGE, TokenLE, TokenEQ, TokenNE:
+			return precComparison
+		case TokenAdd, TokenSub:
+			return precTerm
+		case TokenMul, TokenDiv:
+			return precFactor
+		}
+	case UnaryExpr:
+		return precUnary
+	}
+	return precAtom
+}
+
+// parenthesize renders child in the context of a parent operator with precedence parentPrec, wrapping it in
+// parentheses if omitting them would change its meaning. isRHS distinguishes the right operand of a binary
+// expression, which needs parentheses even at equal precedence since every operator here is left-associative
+// (e.g. "1-(2-3)" is not the same expression as "1-2-3").
+func parenthesize(child Expr, parentPrec int, isRHS bool) string {
+	p := precedence(child)
+	if p < parentPrec || (p == parentPrec && isRHS) {
+		return "(" + child.String() + ")"
+	}
+	return child.String()
+}
+
+func (b BinaryExpr) String() string {
+	p := precedence(b)
+	return parenthesize(b.X, p, false) + string(b.Op) + parenthesize(b.Y, p, true)
+}
+
+func (u UnaryExpr) String() string {
+	return string(u.Op) + parenthesize(u.X, precedence(u), false)
+}
+
+func (c ConstExpr) String() string {
+	switch v := c.Value.(type) {
+	case IntVal:
+		return strconv.Itoa(v.Value)
+	case FloatVal:
+		s := strconv.FormatFloat(v.Value, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0" // keep a whole-number float from parsing back as an IntVal
+		}
+		return s
+	case StringVal:
+		return `"` + v.Value + `"`
+	default:
+		return fmt.Sprintf("%v", c.Value)
+	}
+}
+
+func (b CellRefExpr) String() string {
+	return b.Ref.String()
+}
+
+func (r RangeExpr) String() string {
+	return r.From.String() + ":" + r.To.String()
+}
+
+func (c CallExpr) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = arg.String()
+	}
+	return c.Name + "(" + strings.Join(args, ",") + ")"
+}
+
+// Cells enumerates every CellID within the range, in row-major order. From and To may describe the range in any
+// corner order.
+func (r RangeExpr) Cells() []CellID {
+	minRow, maxRow := r.From.row, r.To.row
+	if minRow > maxRow {
+		minRow, maxRow = maxRow, minRow
+	}
+	minCol, maxCol := r.From.column, r.To.column
+	if minCol > maxCol {
+		minCol, maxCol = maxCol, minCol
+	}
+	var cells []CellID
+	for row := minRow; row <= maxRow; row++ {
+		for col := minCol; col <= maxCol; col++ {
+			cells = append(cells, CellID{row: row, column: col})
+		}
+	}
+	return cells
+}
 
 type Token string
 
 const (
-	TokenAdd  Token = "+"
-	TokenSub        = "-"
-	TokenMul        = "*"
-	TokenDiv        = "/"
-	TokenRPar       = ")"
-	TokenLPar       = "("
+	TokenAdd   Token = "+"
+	TokenSub         = "-"
+	TokenMul         = "*"
+	TokenDiv         = "/"
+	TokenRPar        = ")"
+	TokenLPar        = "("
+	TokenComma       = ","
+	TokenColon       = ":"
+	TokenGT          = ">"
+	TokenLT          = "<"
+	TokenGE          = ">="
+	TokenLE          = "<="
+	TokenEQ          = "=="
+	TokenNE          = "!="
 )
 
-// CellRefs retrieves all cell references which are found in the expression.
+// CellRefs retrieves all cell references which are found in the expression, expanding any RangeExpr into its member
+// CellIDs so every cell inside a range participates in the dependency graph.
 func CellRefs(e Expr) []CellID {
 	if e == nil {
 		return nil
@@ -221,10 +471,20 @@ func CellRefs(e Expr) []CellID {
 			return CellRefs(e.X)
 		}
 		return append(CellRefs(e.X), r...)
+	case UnaryExpr:
+		return CellRefs(e.X)
 	case ConstExpr:
 		return nil
 	case CellRefExpr:
 		return []CellID{e.Ref}
+	case RangeExpr:
+		return e.Cells()
+	case CallExpr:
+		var refs []CellID
+		for _, arg := range e.Args {
+			refs = append(refs, CellRefs(arg)...)
+		}
+		return refs
 	}
 	return nil
 }
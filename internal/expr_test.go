@@ -65,6 +65,104 @@ func Test_ParseExpr(t *testing.T) {
 			input:   "=A1*",
 			wantErr: true,
 		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:     "range",
+			input:    "=A1:A10",
+			expected: rng(CellID{row: 0, column: 0}, CellID{row: 0, column: 9}),
+		},
+		{
+			name:     "call with range arg",
+			input:    "=SUM(A1:A10)",
+			expected: call("SUM", rng(CellID{row: 0, column: 0}, CellID{row: 0, column: 9})),
+		},
+		{
+			name:     "call with multiple args",
+			input:    "=IF(A1>0, B1, C1)",
+			expected: call("IF", gt(cellRef(0, 0), val(0)), cellRef(1, 0), cellRef(2, 0)),
+		},
+		{
+			name:  "calls joined by an operator",
+			input: "=AVERAGE(A1:A10)+MIN(B1:B5)",
+			expected: add(
+				call("AVERAGE", rng(CellID{row: 0, column: 0}, CellID{row: 0, column: 9})),
+				call("MIN", rng(CellID{row: 1, column: 0}, CellID{row: 1, column: 4})),
+			),
+		},
+		{
+			name:     "call with no args",
+			input:    "=COUNT()",
+			expected: call("COUNT"),
+		},
+		{
+			name:    "call missing closing paren",
+			input:   "=SUM(A1:A10",
+			wantErr: true,
+		},
+		{
+			name:    "unexpected bare letters",
+			input:   "=FOO",
+			wantErr: true,
+		},
+		{
+			name:     "decimal literal",
+			input:    "=1.5+2.5",
+			expected: add(fval(1.5), fval(2.5)),
+		},
+		{
+			name:     "string literal",
+			input:    `=IF(A1>0, "yes", "no")`,
+			expected: call("IF", gt(cellRef(0, 0), val(0)), str("yes"), str("no")),
+		},
+		{
+			name:    "unterminated string literal",
+			input:   `="oops`,
+			wantErr: true,
+		},
+		{
+			name:     "parens override precedence",
+			input:    "=(1+2)*3",
+			expected: mul(add(val(1), val(2)), val(3)),
+		},
+		{
+			name:     "parens on the right",
+			input:    "=3*(1+2)",
+			expected: mul(val(3), add(val(1), val(2))),
+		},
+		{
+			name:     "nested parens",
+			input:    "=((1+2))*3",
+			expected: mul(add(val(1), val(2)), val(3)),
+		},
+		{
+			name:     "parens with cell refs and comparison",
+			input:    "=(A1+B1)>(C1*2)",
+			expected: gt(add(cellRef(0, 0), cellRef(1, 0)), mul(cellRef(2, 0), val(2))),
+		},
+		{
+			name:     "unary applied to parenthesized expr",
+			input:    "=-(1+2)",
+			expected: neg(add(val(1), val(2))),
+		},
+		{
+			name:     "parens inside call args",
+			input:    "=SUM((A1+1), (B1+2))",
+			expected: call("SUM", add(cellRef(0, 0), val(1)), add(cellRef(1, 0), val(2))),
+		},
+		{
+			name:    "unclosed parens",
+			input:   "=(1+2",
+			wantErr: true,
+		},
+		{
+			name:    "empty parens",
+			input:   "=()",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -79,6 +177,82 @@ func Test_ParseExpr(t *testing.T) {
 	}
 }
 
+func Test_parsePrimary(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Expr
+		restLen  int
+		wantErr  bool
+	}{
+		{
+			name:     "int literal",
+			input:    "=12",
+			expected: val(12),
+		},
+		{
+			name:     "float literal",
+			input:    "=1.5",
+			expected: fval(1.5),
+		},
+		{
+			name:     "string literal",
+			input:    `="hi"`,
+			expected: str("hi"),
+		},
+		{
+			name:     "cell reference",
+			input:    "=A1",
+			expected: cellRef(0, 0),
+		},
+		{
+			name:     "range",
+			input:    "=A1:B2",
+			expected: rng(CellID{row: 0, column: 0}, CellID{row: 1, column: 1}),
+		},
+		{
+			name:     "parenthesized subexpression",
+			input:    "=(1+2)",
+			expected: add(val(1), val(2)),
+		},
+		{
+			name:     "parenthesized subexpression leaves trailing tokens for the caller",
+			input:    "=(1+2)*3",
+			expected: add(val(1), val(2)),
+			restLen:  2, // '*' and '3' remain for parseFactor to consume
+		},
+		{
+			name:     "call",
+			input:    "=COUNT()",
+			expected: call("COUNT"),
+		},
+		{
+			name:    "unclosed parens",
+			input:   "=(1+2",
+			wantErr: true,
+		},
+		{
+			name:    "bare function name is not a call",
+			input:   "=FOO",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenize(tt.input)
+			assert.NoError(t, err)
+			expr, rest, err := parsePrimary(tokens)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.EqualValues(t, tt.expected, expr)
+			assert.Len(t, rest, tt.restLen)
+		})
+	}
+}
+
 func sub(X, Y Expr) Expr {
 	return BinaryExpr{X: X, Y: Y, Op: TokenSub}
 }
@@ -96,7 +270,15 @@ func div(X, Y Expr) Expr {
 }
 
 func val(x int) Expr {
-	return ConstExpr{Value: x}
+	return ConstExpr{Value: IntVal{Value: x}}
+}
+
+func fval(x float64) Expr {
+	return ConstExpr{Value: FloatVal{Value: x}}
+}
+
+func str(x string) Expr {
+	return ConstExpr{Value: StringVal{Value: x}}
 }
 
 func cellRef(row, col int) Expr {
@@ -105,3 +287,59 @@ func cellRef(row, col int) Expr {
 func neg(X Expr) Expr {
 	return UnaryExpr{X: X, Op: TokenSub}
 }
+
+func gt(X, Y Expr) Expr {
+	return BinaryExpr{X: X, Y: Y, Op: TokenGT}
+}
+
+func rng(from, to CellID) Expr {
+	return RangeExpr{From: from, To: to}
+}
+
+func call(name string, args ...Expr) Expr {
+	return CallExpr{Name: name, Args: args}
+}
+
+func Test_ParseExprRaw(t *testing.T) {
+	expr, err := ParseExprRaw("1+2")
+	assert.NoError(t, err)
+	assert.EqualValues(t, add(val(1), val(2)), expr)
+
+	_, err = ParseExprRaw("=1+2")
+	assert.Error(t, err, "a leading = is not a valid token and should be rejected")
+}
+
+// Test_Expr_String_RoundTrip checks that ParseExprRaw(e.String()) reconstructs e for a variety of expressions,
+// including ones where String() must add parentheses to preserve meaning.
+func Test_Expr_String_RoundTrip(t *testing.T) {
+	formulas := []string{
+		"=1+1",
+		"=A1*13",
+		"=A1*B2+C3*D4",
+		"=-123",
+		"=-123*-456",
+		"=10-20-30",
+		"=10-(20-30)",
+		"=(1+2)*3",
+		"=((1))",
+		"=-(A1+B2)",
+		"=A1>0",
+		"=A1>=0",
+		"=IF(A1>0, B1, C1)",
+		"=SUM(A1:A10)",
+		"=AVERAGE(A1:A10)+MIN(B1:B5)",
+		"=COUNT()",
+		`="hello"`,
+		"=1.5+2",
+	}
+	for _, f := range formulas {
+		t.Run(f, func(t *testing.T) {
+			expr, err := ParseExpr(f)
+			assert.NoError(t, err)
+
+			roundTripped, err := ParseExprRaw(expr.String())
+			assert.NoError(t, err)
+			assert.EqualValues(t, expr, roundTripped)
+		})
+	}
+}
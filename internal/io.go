@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxCells bounds the number of cells LoadCSV and LoadJSON will accept from a single input, guarding against
+// pathological or malicious files that would otherwise exhaust memory one record at a time.
+const MaxCells = 100_000
+
+// ErrTooManyCells is returned by LoadCSV and LoadJSON when an input describes more than MaxCells cells.
+var ErrTooManyCells = errors.New("input exceeds MaxCells")
+
+// equalHeader reports whether got matches want column-for-column.
+func equalHeader(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonCell is the on-disk JSON representation of a single non-empty cell: either a formula ("f") or a literal
+// value ("v"), never both. V is stored as raw JSON rather than behind a separate type tag, so a literal round-trips
+// using its own natural JSON shape (a bare number, or true/false).
+type jsonCell struct {
+	Cell string          `json:"cell"`
+	V    json.RawMessage `json:"v,omitempty"`
+	F    string          `json:"f,omitempty"`
+}
+
+// SaveJSON streams every non-empty cell of s to w as newline-delimited JSON, one object per cell, preserving
+// formulas verbatim so LoadJSON can reconstruct a live formula rather than a frozen number. Use LoadJSON to load
+// the result back.
+func (s *Spreadsheet) SaveJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for cid, cell := range s.cells {
+		rec, err := toJSONCell(cid, cell)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadJSON reads a spreadsheet previously written by SaveJSON from r, applying every cell before recalculating
+// once.
+func LoadJSON(r io.Reader) (*Spreadsheet, error) {
+	s := NewSpreadsheet()
+	dec := json.NewDecoder(r)
+	count := 0
+	for dec.More() {
+		var rec jsonCell
+		if err := dec.Decode(&rec); err != nil {
+			return nil, err
+		}
+		if count++; count > MaxCells {
+			return nil, ErrTooManyCells
+		}
+		cid, val, err := fromJSONCell(rec)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.setCellValue(cid, val); err != nil {
+			return nil, err
+		}
+	}
+	s.Recalculate()
+	return s, nil
+}
+
+// toJSONCell converts cid and cell into the on-disk representation used by SaveJSON.
+func toJSONCell(cid CellID, cell *Cell) (jsonCell, error) {
+	rec := jsonCell{Cell: cid.String()}
+	if cell.raw != "" {
+		rec.F = cell.raw
+		return rec, nil
+	}
+	raw, err := marshalLiteral(cell.currValue)
+	if err != nil {
+		return jsonCell{}, fmt.Errorf("%w: cannot persist cell %s", ErrValueType, rec.Cell)
+	}
+	rec.V = raw
+	return rec, nil
+}
+
+// fromJSONCell recovers the CellID and value rec describes, suitable for passing straight to setCellValue.
+func fromJSONCell(rec jsonCell) (CellID, any, error) {
+	cid, err := ParseCellID(rec.Cell)
+	if err != nil {
+		return CellID{}, nil, err
+	}
+	if rec.F != "" {
+		return cid, rec.F, nil
+	}
+	val, err := unmarshalLiteral(rec.V)
+	return cid, val, err
+}
+
+// marshalLiteral renders v as raw JSON, using the value's own natural JSON representation (a bare number or
+// true/false) rather than a separate type tag.
+func marshalLiteral(v Value) (json.RawMessage, error) {
+	switch v := v.(type) {
+	case IntVal:
+		return json.RawMessage(strconv.Itoa(v.Value)), nil
+	case FloatVal:
+		raw := strconv.FormatFloat(v.Value, 'f', -1, 64)
+		if !strings.Contains(raw, ".") {
+			raw += ".0" // keep a whole-number float distinguishable from an IntVal on the way back in
+		}
+		return json.RawMessage(raw), nil
+	case BoolVal:
+		return json.RawMessage(strconv.FormatBool(v.Value)), nil
+	default:
+		return nil, ErrValueType
+	}
+}
+
+// unmarshalLiteral recovers the Value described by raw, sniffing its JSON shape: true/false is a bool, and a bare
+// number is a float unless it looks like a plain integer.
+func unmarshalLiteral(raw json.RawMessage) (any, error) {
+	s := string(raw)
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if strings.ContainsAny(s, ".eE") {
+		return strconv.ParseFloat(s, 64)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+var csvHeader = []string{"cell", "value"}
+
+// SaveCSV streams every non-empty cell of s to w as CSV, one row per cell, writing each cell's computed value
+// rather than its formula. This is the format most external tools expect, but it is lossy: a cell driven by a
+// formula loads back as a frozen literal, not a live formula. Use SaveCSVWithFormulas to preserve formulas
+// instead, or LoadCSV to load either kind of file back.
+func (s *Spreadsheet) SaveCSV(w io.Writer) error {
+	return s.saveCSV(w, false)
+}
+
+// SaveCSVWithFormulas is like SaveCSV, but writes a formula cell's original "=..." text instead of its computed
+// value, so LoadCSV can reconstruct a live formula rather than a frozen number.
+func (s *Spreadsheet) SaveCSVWithFormulas(w io.Writer) error {
+	return s.saveCSV(w, true)
+}
+
+func (s *Spreadsheet) saveCSV(w io.Writer, preserveFormulas bool) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for cid, cell := range s.cells {
+		val, err := csvValue(cell, preserveFormulas)
+		if err != nil {
+			return err
+		}
+		if err := cw.Write([]string{cid.String(), val}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue renders cell's CSV column: its formula text if preserveFormulas asked for it and cell has one,
+// otherwise the display text of its current value.
+func csvValue(cell *Cell, preserveFormulas bool) (string, error) {
+	if preserveFormulas && cell.raw != "" {
+		return cell.raw, nil
+	}
+	switch v := cell.currValue.(type) {
+	case IntVal:
+		return strconv.Itoa(v.Value), nil
+	case FloatVal:
+		return strconv.FormatFloat(v.Value, 'g', -1, 64), nil
+	case BoolVal:
+		return strconv.FormatBool(v.Value), nil
+	case StringVal:
+		return v.Value, nil
+	case ErrVal:
+		return v.Code, nil
+	default:
+		return "", fmt.Errorf("%w: cannot persist cell value", ErrValueType)
+	}
+}
+
+// LoadCSV reads a spreadsheet previously written by SaveCSV or SaveCSVWithFormulas from r, applying every cell
+// before recalculating once. A value beginning with "=" is loaded as a formula; everything else is loaded as a
+// literal, sniffed the same way SaveCSV's output was produced (true/false, then a number, then plain text).
+func LoadCSV(r io.Reader) (*Spreadsheet, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	if !equalHeader(header, csvHeader) {
+		return nil, fmt.Errorf("unexpected CSV header: %v", header)
+	}
+	s := NewSpreadsheet()
+	count := 0
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if count++; count > MaxCells {
+			return nil, ErrTooManyCells
+		}
+		cid, err := ParseCellID(row[0])
+		if err != nil {
+			return nil, err
+		}
+		val := parseCSVValue(row[1])
+		if err := s.setCellValue(cid, val); err != nil {
+			return nil, err
+		}
+	}
+	s.Recalculate()
+	return s, nil
+}
+
+// parseCSVValue sniffs s the way setCellValue expects: a formula (already a string starting with "="), a bool, a
+// number, or, failing all of those, plain text re-wrapped as a one-constant string formula since setCellValue has
+// no literal-string form of its own.
+func parseCSVValue(s string) any {
+	if strings.HasPrefix(s, "=") {
+		return s
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return fmt.Sprintf("=%q", s)
+}
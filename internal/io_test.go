@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpreadsheet_CSV(t *testing.T) {
+	t.Run("default mode round trips computed values, not formulas", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 12))
+		assert.NoError(t, s.SetCellValue("A2", 1.5))
+		assert.NoError(t, s.SetCellValue("A3", true))
+		assert.NoError(t, s.SetCellValue("B1", "=A1+A2"))
+
+		var buf bytes.Buffer
+		assert.NoError(t, s.SaveCSV(&buf))
+
+		loaded, err := LoadCSV(&buf)
+		assert.NoError(t, err)
+		assertCellValue(t, loaded, "A1", IntVal{12})
+		assertCellValue(t, loaded, "A2", FloatVal{1.5})
+		assertCellValue(t, loaded, "A3", BoolVal{true})
+		assertCellValue(t, loaded, "B1", FloatVal{13.5})
+
+		// B1 was flattened to its computed value, so it no longer tracks A1.
+		assert.NoError(t, loaded.SetCellValue("A1", 100))
+		assertCellValue(t, loaded, "B1", FloatVal{13.5})
+	})
+
+	t.Run("SaveCSVWithFormulas round trips live formulas", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 12))
+		assert.NoError(t, s.SetCellValue("A2", 1.5))
+		assert.NoError(t, s.SetCellValue("B1", "=A1+A2"))
+
+		var buf bytes.Buffer
+		assert.NoError(t, s.SaveCSVWithFormulas(&buf))
+
+		loaded, err := LoadCSV(&buf)
+		assert.NoError(t, err)
+		assertCellValue(t, loaded, "B1", FloatVal{13.5})
+
+		// B1 is still a live formula, so it tracks A1.
+		assert.NoError(t, loaded.SetCellValue("A1", 100))
+		assertCellValue(t, loaded, "B1", FloatVal{101.5})
+	})
+
+	t.Run("bad header is rejected", func(t *testing.T) {
+		_, err := LoadCSV(bytes.NewBufferString("not,the,right,columns\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("reordered header is rejected", func(t *testing.T) {
+		_, err := LoadCSV(bytes.NewBufferString("value,cell\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("too many cells is rejected", func(t *testing.T) {
+		var buf strings.Builder
+		buf.WriteString("cell,value\n")
+		for i := 0; i < MaxCells+1; i++ {
+			buf.WriteString("A1,1\n")
+		}
+		_, err := LoadCSV(strings.NewReader(buf.String()))
+		assert.ErrorIs(t, err, ErrTooManyCells)
+	})
+}
+
+func TestSpreadsheet_JSON(t *testing.T) {
+	t.Run("round trip preserves literals and formulas", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 4))
+		assert.NoError(t, s.SetCellValue("A2", 8))
+		assert.NoError(t, s.SetCellValue("B1", "=SUM(A1:A2)"))
+
+		var buf bytes.Buffer
+		assert.NoError(t, s.SaveJSON(&buf))
+		assert.Contains(t, buf.String(), `"v":4`)
+		assert.Contains(t, buf.String(), `"f":"=SUM(A1:A2)"`)
+
+		loaded, err := LoadJSON(&buf)
+		assert.NoError(t, err)
+		assertCellValue(t, loaded, "A1", IntVal{4})
+		assertCellValue(t, loaded, "A2", IntVal{8})
+		assertCellValue(t, loaded, "B1", IntVal{12})
+
+		// B1 is still a live formula, so it tracks A1 and A2.
+		assert.NoError(t, loaded.SetCellValue("A1", 40))
+		assertCellValue(t, loaded, "B1", IntVal{48})
+	})
+
+	t.Run("float literal keeps its decimal point so it isn't read back as an int", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 4.0))
+
+		var buf bytes.Buffer
+		assert.NoError(t, s.SaveJSON(&buf))
+
+		loaded, err := LoadJSON(&buf)
+		assert.NoError(t, err)
+		assertCellValue(t, loaded, "A1", FloatVal{4.0})
+	})
+
+	t.Run("malformed record is rejected", func(t *testing.T) {
+		_, err := LoadJSON(bytes.NewBufferString(`{"cell":"A1"}` + "\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("too many cells is rejected", func(t *testing.T) {
+		var buf strings.Builder
+		for i := 0; i < MaxCells+1; i++ {
+			buf.WriteString(`{"cell":"A1","v":1}` + "\n")
+		}
+		_, err := LoadJSON(strings.NewReader(buf.String()))
+		assert.ErrorIs(t, err, ErrTooManyCells)
+	})
+}
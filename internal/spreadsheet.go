@@ -14,13 +14,65 @@ var (
 	ErrExprParse   = errors.New("parse error")
 )
 
-// Spreadsheet represents a spreadsheet capable of setting and retrieving cell values. Cells in this spreadsheet store
-// integers. All cells start with a value of 0. Each cell contains either a raw integer value, or an expression in the
-// format
+// Error codes held by ErrVal, following the short-code convention spreadsheet applications like Excel use.
+const (
+	ErrCodeDivZero = "#DIV/0!" // division by zero
+	ErrCodeRef     = "#REF!"   // a formula refers to a cell that cannot be resolved
+	ErrCodeCirc    = "#CIRC!"  // the cell participates in a circular reference
+	ErrCodeValue   = "#VALUE!" // an operand or argument had an unexpected type
+)
+
+// Value is the result of evaluating a cell; every cell holds exactly one concrete Value at a time. The concrete
+// types are IntVal, FloatVal, StringVal, BoolVal, and ErrVal.
+type Value interface {
+	IsValue() // marker method, just for type-safety.
+}
+
+// IntVal is an integer-valued Value.
+type IntVal struct {
+	Value int
+}
+
+// FloatVal is a floating-point-valued Value.
+type FloatVal struct {
+	Value float64
+}
+
+// StringVal is a string-valued Value, produced by string literals in formulas (e.g. ="hello").
+type StringVal struct {
+	Value string
+}
+
+// BoolVal is a boolean-valued Value, produced by comparisons (e.g. =A1>0).
+type BoolVal struct {
+	Value bool
+}
+
+// ErrVal represents a cell holding an error instead of a value, e.g. #DIV/0!. Arithmetic propagates the first ErrVal
+// operand it encounters, matching Excel semantics.
+type ErrVal struct {
+	Code string
+}
+
+func (IntVal) IsValue()    {}
+func (FloatVal) IsValue()  {}
+func (StringVal) IsValue() {}
+func (BoolVal) IsValue()   {}
+func (ErrVal) IsValue()    {}
+
+// Error implements the error interface so an ErrVal can be used wherever an error is expected.
+func (e ErrVal) Error() string {
+	return e.Code
+}
+
+// Spreadsheet represents a spreadsheet capable of setting and retrieving cell values. Cells in this spreadsheet hold
+// a Value apiece. All cells start with a value of IntVal{0}. Each cell contains either a raw literal value, or an
+// expression in the format
 //
 //	=A1+B2*C3+12
 //
-// Only addition and multiplication are supported as binary operations.
+// Expressions may also call named functions over ranges of cells, e.g. =SUM(A1:A10) or =IF(A1>0, B1, C1). See
+// RegisterFunc to add custom functions to the ones built in.
 type Spreadsheet struct {
 	// cells maps from CellID to cells.
 	cells map[CellID]*Cell
@@ -28,69 +80,186 @@ type Spreadsheet struct {
 	refersTo map[CellID]map[CellID]struct{}
 	// referredFrom maps cells to the set of all cells that directly reference them. It is the inverse of refersTo.
 	referredFrom map[CellID]map[CellID]struct{}
+	// funcs maps function names (as used in formulas, e.g. SUM) to their implementation.
+	funcs map[string]func([]int) (int, error)
+	// dirty holds every cell that may need to be recomputed before its currValue can be trusted again. Cells are
+	// added by markDirty and removed by evalDirty once their value is brought up to date.
+	dirty map[CellID]struct{}
 }
 
 func NewSpreadsheet() *Spreadsheet {
-	return &Spreadsheet{
+	s := &Spreadsheet{
 		cells:        make(map[CellID]*Cell),
 		refersTo:     make(map[CellID]map[CellID]struct{}),
 		referredFrom: make(map[CellID]map[CellID]struct{}),
+		funcs:        make(map[string]func([]int) (int, error)),
+		dirty:        make(map[CellID]struct{}),
+	}
+	for name, fn := range defaultFuncs() {
+		s.funcs[name] = fn
+	}
+	return s
+}
+
+// RegisterFunc registers fn as the implementation of the named function, making it callable from formulas as
+// NAME(...). Registering a name that is already in use, including one of the built-ins, overwrites it.
+func (s *Spreadsheet) RegisterFunc(name string, fn func([]int) (int, error)) {
+	s.funcs[name] = fn
+}
+
+// defaultFuncs returns the built-in functions every Spreadsheet is seeded with.
+func defaultFuncs() map[string]func([]int) (int, error) {
+	return map[string]func([]int) (int, error){
+		"SUM": func(args []int) (int, error) {
+			sum := 0
+			for _, a := range args {
+				sum += a
+			}
+			return sum, nil
+		},
+		"AVERAGE": func(args []int) (int, error) {
+			if len(args) == 0 {
+				return 0, nil
+			}
+			sum := 0
+			for _, a := range args {
+				sum += a
+			}
+			return sum / len(args), nil
+		},
+		"MIN": func(args []int) (int, error) {
+			if len(args) == 0 {
+				return 0, nil
+			}
+			min := args[0]
+			for _, a := range args[1:] {
+				if a < min {
+					min = a
+				}
+			}
+			return min, nil
+		},
+		"MAX": func(args []int) (int, error) {
+			if len(args) == 0 {
+				return 0, nil
+			}
+			max := args[0]
+			for _, a := range args[1:] {
+				if a > max {
+					max = a
+				}
+			}
+			return max, nil
+		},
+		"COUNT": func(args []int) (int, error) {
+			return len(args), nil
+		},
+		"IF": func(args []int) (int, error) {
+			if len(args) != 3 {
+				return 0, fmt.Errorf("IF requires exactly 3 arguments, got %d", len(args))
+			}
+			if args[0] != 0 {
+				return args[1], nil
+			}
+			return args[2], nil
+		},
 	}
 }
 
 // Cell represents a single cell of a spreadsheet.
 type Cell struct {
-	currValue int   // currValue is the current value of this cell
-	expr      *Expr // expr describes the expression used to compute
+	currValue Value  // currValue is the current value of this cell
+	expr      *Expr  // expr describes the expression used to compute
+	raw       string // raw is the original formula text (e.g. "=A1+B2"), empty for literal cells
 }
 
-// SetCellValue sets the value of the cell with the provided cell ID. Val can be either an int or a valid string
-// expression which the cell ought to contain. An error is returned if the expression cannot be parsed, an invalid
-// cellID is provided, or val is some type other than int or string.
+// SetCellValue sets the value of the cell with the provided cell ID. Val can be an int, float64, bool, or a valid
+// string expression which the cell ought to contain. An error is returned if the expression cannot be parsed, an
+// invalid cellID is provided, or val is some type other than int, float64, bool, or string.
 func (s *Spreadsheet) SetCellValue(cellID string, val any) error {
 	cid, err := ParseCellID(cellID)
 	if err != nil {
 		return err
 	}
+	if err := s.setCellValue(cid, val); err != nil {
+		return err
+	}
+	s.Recalculate()
+	return nil
+}
+
+// SetCellValues sets every cell value in values, deferring recalculation until all of them have been applied. On
+// large sheets this is substantially cheaper than calling SetCellValue in a loop, since a dependent shared by
+// several of the changed cells is only recomputed once instead of once per write. If any cellID or value is
+// invalid, SetCellValues returns an error immediately, leaving values applied so far in place.
+func (s *Spreadsheet) SetCellValues(values map[string]any) error {
+	for cellID, val := range values {
+		cid, err := ParseCellID(cellID)
+		if err != nil {
+			return err
+		}
+		if err := s.setCellValue(cid, val); err != nil {
+			return err
+		}
+	}
+	s.Recalculate()
+	return nil
+}
+
+// setCellValue applies val to the cell at cid, rewires the dependency graph to match, and marks cid and its
+// dependents dirty. It does not recalculate; callers must call Recalculate once every value has been applied.
+func (s *Spreadsheet) setCellValue(cid CellID, val any) error {
 	if _, ok := s.cells[cid]; !ok {
 		s.cells[cid] = &Cell{}
 	}
 	switch val := val.(type) {
 	case int:
-		s.cells[cid].expr = nil      // unset expr
-		s.cells[cid].currValue = val // set value
+		s.cells[cid].expr = nil              // unset expr
+		s.cells[cid].raw = ""                // unset raw formula text
+		s.cells[cid].currValue = IntVal{val} // set value
+	case float64:
+		s.cells[cid].expr = nil
+		s.cells[cid].raw = ""
+		s.cells[cid].currValue = FloatVal{val}
+	case bool:
+		s.cells[cid].expr = nil
+		s.cells[cid].raw = ""
+		s.cells[cid].currValue = BoolVal{val}
 	case string:
 		expr, err := ParseExpr(val)
 		if err != nil {
 			return err
 		}
-		s.cells[cid].expr = &expr  // set expr
-		s.cells[cid].currValue = 0 // unset value
+		s.cells[cid].expr = &expr          // set expr
+		s.cells[cid].raw = val             // preserve the original formula text
+		s.cells[cid].currValue = IntVal{0} // unset value
 	default:
-		return fmt.Errorf("%w: only int and string are allowed", ErrValueType)
+		return fmt.Errorf("%w: only int, float64, bool, and string are allowed", ErrValueType)
 	}
-	return s.refresh(cid)
+	s.rewire(cid)
+	s.markDirty(cid)
+	return nil
 }
 
 // GetCellValue retrieves the value of the cell with the provided ID. An error is returned if the provided string could
 // not be parsed as a valid cell ID.
-func (s *Spreadsheet) GetCellValue(cellID string) (int, error) {
+func (s *Spreadsheet) GetCellValue(cellID string) (Value, error) {
 	cid, err := ParseCellID(cellID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	cell, ok := s.cells[cid]
 	if !ok {
-		return 0, nil // empty cells always have a value of zero.
+		return IntVal{0}, nil // empty cells always have a value of zero.
 	}
-	return cell.currValue, nil // all cell values are pre-computed by SetCellValue during refresh.
+	return cell.currValue, nil // all cell values are pre-computed by SetCellValue during recalculation.
 }
 
 // eval evaluates the value of the provided cell.
-func (s *Spreadsheet) eval(cid CellID) int {
+func (s *Spreadsheet) eval(cid CellID) Value {
 	cell, ok := s.cells[cid]
 	if !ok {
-		return 0 // all missing cells have a value of 0.
+		return IntVal{0} // all missing cells have a value of 0.
 	}
 	if cell.expr == nil {
 		return cell.currValue
@@ -98,30 +267,42 @@ func (s *Spreadsheet) eval(cid CellID) int {
 	return s.evalExpr(*cell.expr)
 }
 
-// evalExpr evaluates the provided expression. results reported by evalExpr are only valid when cells are called in
-// topological order during refresh. evalExpr does not track circular references on its own.
-func (s *Spreadsheet) evalExpr(expr Expr) int {
+// evalExpr evaluates the provided expression. Results reported by evalExpr are only valid once every cell it
+// depends on holds an up-to-date value; evalDirty guarantees this by pulling dependencies through refersTo before
+// calling evalExpr. evalExpr does not track circular references on its own; evalDirty substitutes
+// ErrVal{Code: ErrCodeCirc} for any cell found to be part of a cycle before evalExpr is ever called on it.
+//
+// Any ErrVal operand short-circuits the rest of the expression and is returned as-is, matching Excel semantics.
+func (s *Spreadsheet) evalExpr(expr Expr) Value {
 	switch expr := expr.(type) {
 	case UnaryExpr:
+		x := s.evalExpr(expr.X)
+		if errVal, ok := x.(ErrVal); ok {
+			return errVal
+		}
 		if expr.Op == TokenSub {
-			x := s.evalExpr(expr.X)
-			return -x
+			return negate(x)
 		}
 	case BinaryExpr:
 		x := s.evalExpr(expr.X)
+		if errVal, ok := x.(ErrVal); ok {
+			return errVal
+		}
 		y := s.evalExpr(expr.Y)
+		if errVal, ok := y.(ErrVal); ok {
+			return errVal
+		}
 		switch expr.Op {
 		case TokenAdd:
-			return x + y
-		case TokenMul:
-			return x * y
+			return arith(x, y, func(a, b int) int { return a + b }, func(a, b float64) float64 { return a + b })
 		case TokenSub:
-			return x - y
+			return arith(x, y, func(a, b int) int { return a - b }, func(a, b float64) float64 { return a - b })
+		case TokenMul:
+			return arith(x, y, func(a, b int) int { return a * b }, func(a, b float64) float64 { return a * b })
 		case TokenDiv:
-			if y == 0 {
-				return 0 // refuse to divide by zero TODO: alert the user; like a circ ref
-			}
-			return x / y
+			return divide(x, y)
+		case TokenGT, TokenLT, TokenGE, TokenLE, TokenEQ, TokenNE:
+			return compare(expr.Op, x, y)
 		}
 	case ConstExpr:
 		return expr.Value
@@ -129,131 +310,338 @@ func (s *Spreadsheet) evalExpr(expr Expr) int {
 		if cell, ok := s.cells[expr.Ref]; ok {
 			return cell.currValue
 		}
-		return 0 // empty cells are zeroes.
+		return IntVal{0} // empty cells are zeroes.
+	case RangeExpr:
+		// a bare range outside of a function call behaves like SUM() over its cells.
+		sum := 0
+		for _, cid := range expr.Cells() {
+			n, errVal := toIntValue(s.evalExpr(CellRefExpr{Ref: cid}))
+			if errVal != nil {
+				return errVal
+			}
+			sum += n
+		}
+		return IntVal{sum}
+	case CallExpr:
+		fn, ok := s.funcs[expr.Name]
+		if !ok {
+			return ErrVal{Code: ErrCodeValue}
+		}
+		args, errVal := s.evalArgs(expr.Args)
+		if errVal != nil {
+			return errVal
+		}
+		result, err := fn(args)
+		if err != nil {
+			return ErrVal{Code: ErrCodeValue}
+		}
+		return IntVal{result}
 	}
-	return 0 // "unreachable" if parseExpr is valid
+	return ErrVal{Code: ErrCodeValue} // "unreachable" if parseExpr is valid
 }
 
-// refresh refreshes the spreadsheet, with the knowledge that cell cid was just updated.
-func (s *Spreadsheet) refresh(cid CellID) error {
-	cell, ok := s.cells[cid]
-	if !ok {
-		return nil // nothing to see here
+// evalArgs evaluates a list of call arguments for the (legacy) int-based function registry, expanding any RangeExpr
+// into one value per cell in the range. If any argument cannot be coerced to an int, evalArgs returns that ErrVal.
+func (s *Spreadsheet) evalArgs(args []Expr) ([]int, Value) {
+	var vals []int
+	for _, arg := range args {
+		if r, ok := arg.(RangeExpr); ok {
+			for _, cid := range r.Cells() {
+				n, errVal := toIntValue(s.evalExpr(CellRefExpr{Ref: cid}))
+				if errVal != nil {
+					return nil, errVal
+				}
+				vals = append(vals, n)
+			}
+			continue
+		}
+		n, errVal := toIntValue(s.evalExpr(arg))
+		if errVal != nil {
+			return nil, errVal
+		}
+		vals = append(vals, n)
 	}
+	return vals, nil
+}
 
-	// update refersTo and referredFrom (if needed)
-	if cell.expr != nil {
-		// unset referredFrom refs and clear out refersTo refs.
-		for ref := range s.refersTo[cid] {
-			delete(s.referredFrom[ref], cid)
-		}
-		maps.Clear(s.refersTo[cid])
-		// update the graph with new refs
-		for _, ref := range CellRefs(*cell.expr) {
-			s.addCellReferral(cid, ref)
+// toIntValue coerces v to an int for use with the int-based function registry, returning a non-nil ErrVal if v
+// cannot be coerced.
+func toIntValue(v Value) (int, Value) {
+	switch v := v.(type) {
+	case IntVal:
+		return v.Value, nil
+	case FloatVal:
+		return int(v.Value), nil
+	case BoolVal:
+		if v.Value {
+			return 1, nil
 		}
+		return 0, nil
+	case ErrVal:
+		return 0, v
+	default:
+		return 0, ErrVal{Code: ErrCodeValue}
 	}
+}
 
-	// get start nodes; these are the cells transitively referring to cid which are not referred to by anyone else.
-	// They will form the start point of the topological sort we're about to do to ensure that we re-evaluate cells in
-	// the correct order.
-	roots := s.rootReferrers(cid)
+// asFloat coerces v to a float64, reporting false if v is not a numeric value.
+func asFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case IntVal:
+		return float64(v.Value), true
+	case FloatVal:
+		return v.Value, true
+	default:
+		return 0, false
+	}
+}
 
-	// Topological sort to re-evaluate cells in the correct order & check for circular references at the same time.
-	order, err := s.topSort(roots)
-	if err != nil {
-		return err // circular reference detected; bail!
-		// FIXME: be more user-friendly like Excel and allow circular references to exist without throwing an error.
+// negate negates a numeric value, returning ErrCodeValue for non-numeric operands.
+func negate(v Value) Value {
+	switch v := v.(type) {
+	case IntVal:
+		return IntVal{-v.Value}
+	case FloatVal:
+		return FloatVal{-v.Value}
+	default:
+		return ErrVal{Code: ErrCodeValue}
+	}
+}
+
+// arith applies iop or fop to x and y depending on whether either operand is a FloatVal, returning ErrCodeValue if
+// either operand is non-numeric.
+func arith(x, y Value, iop func(a, b int) int, fop func(a, b float64) float64) Value {
+	ix, xIsInt := x.(IntVal)
+	iy, yIsInt := y.(IntVal)
+	if xIsInt && yIsInt {
+		return IntVal{iop(ix.Value, iy.Value)}
+	}
+	xf, xok := asFloat(x)
+	yf, yok := asFloat(y)
+	if !xok || !yok {
+		return ErrVal{Code: ErrCodeValue}
 	}
+	return FloatVal{fop(xf, yf)}
+}
 
-	// re-evaluate all the cells found in topological order.
-	for _, cid := range order {
-		if cell, ok := s.cells[cid]; ok {
-			cell.currValue = s.eval(cid)
+// divide divides x by y, returning ErrCodeDivZero if y is zero and ErrCodeValue if either operand is non-numeric.
+func divide(x, y Value) Value {
+	if ix, ok := x.(IntVal); ok {
+		if iy, ok := y.(IntVal); ok {
+			if iy.Value == 0 {
+				return ErrVal{Code: ErrCodeDivZero}
+			}
+			return IntVal{ix.Value / iy.Value}
 		}
 	}
-	return nil
+	xf, xok := asFloat(x)
+	yf, yok := asFloat(y)
+	if !xok || !yok {
+		return ErrVal{Code: ErrCodeValue}
+	}
+	if yf == 0 {
+		return ErrVal{Code: ErrCodeDivZero}
+	}
+	return FloatVal{xf / yf}
 }
 
-// addCellReferral adds edges to the graph so that source refers to target.
-func (s *Spreadsheet) addCellReferral(source, target CellID) {
-	if _, ok := s.refersTo[source]; !ok {
-		s.refersTo[source] = make(map[CellID]struct{})
+// compare applies the given comparison operator to x and y. Numeric operands are compared by value; non-numeric
+// operands only support == and != (by type and value).
+func compare(op Token, x, y Value) Value {
+	if xf, xok := asFloat(x); xok {
+		if yf, yok := asFloat(y); yok {
+			switch op {
+			case TokenGT:
+				return BoolVal{xf > yf}
+			case TokenLT:
+				return BoolVal{xf < yf}
+			case TokenGE:
+				return BoolVal{xf >= yf}
+			case TokenLE:
+				return BoolVal{xf <= yf}
+			case TokenEQ:
+				return BoolVal{xf == yf}
+			case TokenNE:
+				return BoolVal{xf != yf}
+			}
+		}
 	}
-	if _, ok := s.referredFrom[target]; !ok {
-		s.referredFrom[target] = make(map[CellID]struct{})
+	switch op {
+	case TokenEQ:
+		return BoolVal{valuesEqual(x, y)}
+	case TokenNE:
+		return BoolVal{!valuesEqual(x, y)}
+	default:
+		return ErrVal{Code: ErrCodeValue}
 	}
+}
 
-	s.refersTo[source][target] = struct{}{}
-	s.referredFrom[target][source] = struct{}{}
+// valuesEqual reports whether x and y hold the same type and value.
+func valuesEqual(x, y Value) bool {
+	switch x := x.(type) {
+	case IntVal:
+		y, ok := y.(IntVal)
+		return ok && x.Value == y.Value
+	case FloatVal:
+		y, ok := y.(FloatVal)
+		return ok && x.Value == y.Value
+	case StringVal:
+		y, ok := y.(StringVal)
+		return ok && x.Value == y.Value
+	case BoolVal:
+		y, ok := y.(BoolVal)
+		return ok && x.Value == y.Value
+	case ErrVal:
+		y, ok := y.(ErrVal)
+		return ok && x.Code == y.Code
+	default:
+		return false
+	}
 }
 
-// rootReferrers retrieves all unreferenced cells which transitively refer to cid.
-func (s *Spreadsheet) rootReferrers(cid CellID) []CellID {
-	// BFS from cid over all ancestors to find starting cells
+// rewire updates refersTo and referredFrom so they match cid's current expression.
+func (s *Spreadsheet) rewire(cid CellID) {
+	cell, ok := s.cells[cid]
+	if !ok {
+		return
+	}
+	// unset referredFrom refs and clear out refersTo refs.
+	for ref := range s.refersTo[cid] {
+		delete(s.referredFrom[ref], cid)
+	}
+	maps.Clear(s.refersTo[cid])
+	if cell.expr != nil {
+		// update the graph with new refs
+		for _, ref := range CellRefs(*cell.expr) {
+			s.addCellReferral(cid, ref)
+		}
+	}
+}
+
+// markDirty marks cid, and every cell that transitively depends on it via referredFrom, dirty. Dirty cells are
+// recomputed by the next call to Recalculate; cells never added here keep whatever value they already hold.
+func (s *Spreadsheet) markDirty(cid CellID) {
 	frontier := []CellID{cid}
-	seen := map[CellID]struct{}{cid: {}}
-	var startCells []CellID
 	for len(frontier) > 0 {
 		curr := frontier[0]
 		frontier = frontier[1:]
-		if referrers, ok := s.referredFrom[curr]; !ok || len(referrers) == 0 {
-			startCells = append(startCells, curr)
+		if _, ok := s.dirty[curr]; ok {
+			continue // already marked (and its dependents already queued)
+		}
+		s.dirty[curr] = struct{}{}
+		for dependent := range s.referredFrom[curr] {
+			frontier = append(frontier, dependent)
 		}
+	}
+}
 
-		for referer := range s.referredFrom[curr] {
-			if _, sawReferer := seen[referer]; !sawReferer {
-				frontier = append(frontier, referer)
-				seen[referer] = struct{}{}
+// visitState tracks the current recursion path through evalDirty, in order, so that when a cycle is detected only
+// the true cycle suffix of the path (not every ancestor that merely happens to be on the call stack) gets poisoned.
+type visitState struct {
+	stack []CellID
+	index map[CellID]int // position of each cell currently on stack, for O(1) cycle detection
+}
+
+// Recalculate brings every dirty cell up to date. Cells are evaluated lazily, in dependency order, by recursing
+// through refersTo; a cell whose recomputed value matches its previous one stops the recursion from re-evaluating
+// its own dependents, since nothing they see has actually changed.
+func (s *Spreadsheet) Recalculate() {
+	visiting := &visitState{index: make(map[CellID]int)}
+	for cid := range s.dirty {
+		s.evalDirty(cid, visiting)
+	}
+}
+
+// evalDirty returns an up-to-date value for cid, recursively bringing its dependencies up to date first. Clean
+// cells (not present in s.dirty) return their cached currValue without recomputing. Circular references are
+// detected via visiting, the temp-mark stack from the same trick a DFS-based topological sort uses: any cell still
+// present on the stack when it is reached again is part of the cycle currently being explored; cells that only
+// appear earlier on the stack are ancestors of the cycle, not members of it, and must be left alone.
+func (s *Spreadsheet) evalDirty(cid CellID, visiting *visitState) Value {
+	cell, ok := s.cells[cid]
+	if !ok {
+		return IntVal{0} // all missing cells have a value of 0.
+	}
+	if _, dirty := s.dirty[cid]; !dirty {
+		return cell.currValue // already up to date, either untouched or evaluated earlier this pass
+	}
+	if idx, ok := visiting.index[cid]; ok {
+		// only the suffix of the stack from cid's earlier occurrence to the top is actually part of the cycle.
+		for _, node := range visiting.stack[idx:] {
+			if c, ok := s.cells[node]; ok {
+				c.currValue = ErrVal{Code: ErrCodeCirc}
 			}
+			delete(s.dirty, node)
 		}
+		return ErrVal{Code: ErrCodeCirc}
 	}
-	if len(startCells) == 0 {
-		return []CellID{cid}
+	if cell.expr == nil {
+		delete(s.dirty, cid) // a literal doesn't depend on anything, so it's already up to date.
+		return cell.currValue
 	}
-	return startCells
-}
 
-// ErrCircRef is returned whenever a circular reference is added.
-var ErrCircRef = errors.New("circular reference detected")
+	// prev must be captured before recursing: a cycle discovered below cid may poison cell.currValue directly,
+	// and comparing against that poisoned value instead of the value from before this pass would make the
+	// change look like a no-op, wrongly leaving cid's own dependents stuck with stale values.
+	prev := cell.currValue
 
-// topSort implements a topological sort. Only nodes reachable from the provided startNodes will be sorted and included
-// in the output.
-func (s *Spreadsheet) topSort(startNodes []CellID) ([]CellID, error) {
-	var result []CellID
+	visiting.index[cid] = len(visiting.stack)
+	visiting.stack = append(visiting.stack, cid)
+	for ref := range s.refersTo[cid] {
+		s.evalDirty(ref, visiting) // pull each dependency up to date before evaluating cid's own expression.
+	}
+	visiting.stack = visiting.stack[:len(visiting.stack)-1]
+	delete(visiting.index, cid)
 
-	perm := make(map[CellID]struct{})
-	temp := make(map[CellID]struct{})
+	if _, dirty := s.dirty[cid]; !dirty {
+		// cid was poisoned as part of a cycle closed somewhere below it; that already set currValue and left
+		// cid's dependents dirty for re-evaluation, so there's nothing left to do here.
+		return cell.currValue
+	}
 
-	// recursive DFS to perform a topological sort without destroying the graph structure.
-	var visit func(curr CellID) error
-	visit = func(curr CellID) error {
-		if _, permMark := perm[curr]; permMark {
-			return nil
-		}
-		if _, tempMark := temp[curr]; tempMark {
-			return ErrCircRef
-		}
-		temp[curr] = struct{}{}
+	next := s.evalExpr(*cell.expr)
+	cell.currValue = next
+	delete(s.dirty, cid)
+	if valuesEqual(prev, next) {
+		s.unmarkClean(cid) // nothing downstream can have changed as a result of this write; stop propagating.
+	}
+	return next
+}
 
-		for neighbor := range s.refersTo[curr] {
-			if err := visit(neighbor); err != nil {
-				return err
+// unmarkClean removes cid's direct dependents from the dirty set, and recurses into theirs in turn, as long as none
+// of a dependent's other dependencies are still dirty. It undoes the over-approximation markDirty makes, once
+// evalDirty discovers that a cell's value didn't actually change.
+func (s *Spreadsheet) unmarkClean(cid CellID) {
+	for dependent := range s.referredFrom[cid] {
+		if _, ok := s.dirty[dependent]; !ok {
+			continue // already clean
+		}
+		stillDirty := false
+		for dep := range s.refersTo[dependent] {
+			if _, ok := s.dirty[dep]; ok {
+				stillDirty = true
+				break
 			}
 		}
-		delete(temp, curr)
-		perm[curr] = struct{}{}
-		result = append(result, curr)
-		return nil
+		if stillDirty {
+			continue
+		}
+		delete(s.dirty, dependent)
+		s.unmarkClean(dependent)
 	}
+}
 
-	// visit each of the starting nodes
-	for _, node := range startNodes {
-		if err := visit(node); err != nil {
-			return nil, err
-		}
+// addCellReferral adds edges to the graph so that source refers to target.
+func (s *Spreadsheet) addCellReferral(source, target CellID) {
+	if _, ok := s.refersTo[source]; !ok {
+		s.refersTo[source] = make(map[CellID]struct{})
 	}
-	return result, nil
+	if _, ok := s.referredFrom[target]; !ok {
+		s.referredFrom[target] = make(map[CellID]struct{})
+	}
+
+	s.refersTo[source][target] = struct{}{}
+	s.referredFrom[target][source] = struct{}{}
 }
 
 // CellID represents a column and row of our spreadsheet.
@@ -289,6 +677,25 @@ func ParseCellID(str string) (CellID, error) {
 	return CellID{row: rowIdx, column: colIdx - 1}, nil
 }
 
+// String renders c in the same notation ParseCellID accepts, e.g. CellID{row: 0, column: 0} becomes "A1". It is the
+// inverse of ParseCellID.
+func (c CellID) String() string {
+	return encodeRowExpr(c.row) + strconv.Itoa(c.column+1)
+}
+
+// encodeRowExpr encodes row, a zero-indexed 'base-26' value, into its letter representation. It is the inverse of
+// decodeRowExpr.
+func encodeRowExpr(row int) string {
+	n := row + 1
+	var letters []byte
+	for n > 0 {
+		n--
+		letters = append([]byte{byte('A' + n%26)}, letters...)
+		n /= 26
+	}
+	return string(letters)
+}
+
 // decodeRowExpr decodes a 'base-26' row expression into its equivalent integer, returning an error if it is unable to
 // do so.
 //
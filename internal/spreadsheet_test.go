@@ -12,20 +12,20 @@ func TestSpreadsheet(t *testing.T) {
 
 		assert.NoError(t, s.SetCellValue("B1", "=A1+A2+A3"))
 		assert.NoError(t, s.SetCellValue("A1", 12))
-		assertCellValue(t, s, "B1", 12)
+		assertCellValue(t, s, "B1", IntVal{12})
 
 		assert.NoError(t, s.SetCellValue("A2", 12))
-		assertCellValue(t, s, "B1", 24)
+		assertCellValue(t, s, "B1", IntVal{24})
 
 		assert.NoError(t, s.SetCellValue("A3", 12))
-		assertCellValue(t, s, "B1", 36)
+		assertCellValue(t, s, "B1", IntVal{36})
 
-		assertCellValue(t, s, "A1", 12)
-		assertCellValue(t, s, "A2", 12)
-		assertCellValue(t, s, "A3", 12)
+		assertCellValue(t, s, "A1", IntVal{12})
+		assertCellValue(t, s, "A2", IntVal{12})
+		assertCellValue(t, s, "A3", IntVal{12})
 
 		assert.NoError(t, s.SetCellValue("A2", 24))
-		assertCellValue(t, s, "B1", 48)
+		assertCellValue(t, s, "B1", IntVal{48})
 	})
 
 	t.Run("reference chain", func(t *testing.T) {
@@ -39,7 +39,7 @@ func TestSpreadsheet(t *testing.T) {
 		assert.NoError(t, s.SetCellValue("A6", "=A7"))
 		assert.NoError(t, s.SetCellValue("A7", 12))
 
-		assertCellValue(t, s, "A1", 12)
+		assertCellValue(t, s, "A1", IntVal{12})
 	})
 
 	t.Run("fibonacci", func(t *testing.T) {
@@ -53,20 +53,40 @@ func TestSpreadsheet(t *testing.T) {
 			assert.NoError(t, s.SetCellValue(cell, expr))
 		}
 
-		assertCellValue(t, s, "A14", 233)
+		assertCellValue(t, s, "A14", IntVal{233})
+	})
+
+	t.Run("division by zero", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 12))
+		assert.NoError(t, s.SetCellValue("A2", 0))
+		assert.NoError(t, s.SetCellValue("B1", "=A1/A2"))
+		assertCellValue(t, s, "B1", ErrVal{Code: ErrCodeDivZero})
+	})
+
+	t.Run("errors propagate through arithmetic", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 12))
+		assert.NoError(t, s.SetCellValue("A2", 0))
+		assert.NoError(t, s.SetCellValue("B1", "=A1/A2"))
+		assert.NoError(t, s.SetCellValue("C1", "=B1+1"))
+		assertCellValue(t, s, "C1", ErrVal{Code: ErrCodeDivZero})
 	})
 
 	t.Run("circref tiny cycle", func(t *testing.T) {
 		s := NewSpreadsheet()
 
 		assert.NoError(t, s.SetCellValue("A1", "=A2"))
-		assert.ErrorIs(t, s.SetCellValue("A2", "=A1"), ErrCircRef)
+		assert.NoError(t, s.SetCellValue("A2", "=A1"))
+		assertCellValue(t, s, "A1", ErrVal{Code: ErrCodeCirc})
+		assertCellValue(t, s, "A2", ErrVal{Code: ErrCodeCirc})
 	})
 
 	t.Run("circref selfref", func(t *testing.T) {
 		s := NewSpreadsheet()
 
-		assert.ErrorIs(t, s.SetCellValue("A1", "=A1"), ErrCircRef)
+		assert.NoError(t, s.SetCellValue("A1", "=A1"))
+		assertCellValue(t, s, "A1", ErrVal{Code: ErrCodeCirc})
 	})
 
 	t.Run("big cycle", func(t *testing.T) {
@@ -77,15 +97,168 @@ func TestSpreadsheet(t *testing.T) {
 			cell2 := fmt.Sprintf("=A%d", i+1)
 			assert.NoError(t, s.SetCellValue(cell1, cell2))
 		}
-		assert.ErrorIs(t, s.SetCellValue("A15", "=A1"), ErrCircRef)
+		assert.NoError(t, s.SetCellValue("A15", "=A1"))
+		assertCellValue(t, s, "A1", ErrVal{Code: ErrCodeCirc})
+	})
+
+	t.Run("dependent of a cell that becomes circular is not left stale", func(t *testing.T) {
+		s := NewSpreadsheet()
+
+		assert.NoError(t, s.SetCellValue("A1", "=B1"))
+		assert.NoError(t, s.SetCellValue("B1", "=C1"))
+		assert.NoError(t, s.SetCellValue("C1", 1))
+		assert.NoError(t, s.SetCellValue("D1", "=A1+1"))
+		assertCellValue(t, s, "D1", IntVal{2})
+
+		// closes A1 -> B1 -> C1 -> A1 into a cycle; D1 depends on A1 but is not itself part of the cycle.
+		assert.NoError(t, s.SetCellValue("C1", "=A1"))
+
+		assertCellValue(t, s, "A1", ErrVal{Code: ErrCodeCirc})
+		assertCellValue(t, s, "D1", ErrVal{Code: ErrCodeCirc})
+	})
+}
+
+func TestSpreadsheet_SetCellValues(t *testing.T) {
+	t.Run("bulk set recalculates a shared dependent once", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("B1", "=A1+A2"))
+
+		assert.NoError(t, s.SetCellValues(map[string]any{
+			"A1": 3,
+			"A2": 4,
+		}))
+		assertCellValue(t, s, "B1", IntVal{7})
+	})
+
+	t.Run("deferred writes still see each other's edges", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValues(map[string]any{
+			"A1": 1,
+			"A2": "=A1+1",
+			"A3": "=A2+1",
+		}))
+		assertCellValue(t, s, "A3", IntVal{3})
+	})
+
+	t.Run("invalid cell ID stops before recalculating", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.Error(t, s.SetCellValues(map[string]any{
+			"A1":       1,
+			"not-a-id": 2,
+		}))
+	})
+}
+
+func TestSpreadsheet_functions(t *testing.T) {
+	t.Run("SUM over a range", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 1))
+		assert.NoError(t, s.SetCellValue("A2", 2))
+		assert.NoError(t, s.SetCellValue("A3", 3))
+		assert.NoError(t, s.SetCellValue("B1", "=SUM(A1:A3)"))
+		assertCellValue(t, s, "B1", IntVal{6})
+
+		assert.NoError(t, s.SetCellValue("A2", 20))
+		assertCellValue(t, s, "B1", IntVal{24})
+	})
+
+	t.Run("AVERAGE, MIN, MAX, COUNT", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 4))
+		assert.NoError(t, s.SetCellValue("A2", 8))
+		assert.NoError(t, s.SetCellValue("B1", "=AVERAGE(A1:A2)"))
+		assert.NoError(t, s.SetCellValue("B2", "=MIN(A1:A2)"))
+		assert.NoError(t, s.SetCellValue("B3", "=MAX(A1:A2)"))
+		assert.NoError(t, s.SetCellValue("B4", "=COUNT(A1:A2)"))
+		assertCellValue(t, s, "B1", IntVal{6})
+		assertCellValue(t, s, "B2", IntVal{4})
+		assertCellValue(t, s, "B3", IntVal{8})
+		assertCellValue(t, s, "B4", IntVal{2})
+	})
+
+	t.Run("IF with a comparison", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 1))
+		assert.NoError(t, s.SetCellValue("B1", 2))
+		assert.NoError(t, s.SetCellValue("C1", 3))
+		assert.NoError(t, s.SetCellValue("D1", "=IF(A1>0, B1, C1)"))
+		assertCellValue(t, s, "D1", IntVal{2})
+
+		assert.NoError(t, s.SetCellValue("A1", 0))
+		assertCellValue(t, s, "D1", IntVal{3})
+	})
+
+	t.Run("range participates in the dependency graph", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("B1", "=SUM(A1:A3)"))
+		assert.NoError(t, s.SetCellValue("A2", 5))
+		assertCellValue(t, s, "B1", IntVal{5})
+	})
+
+	t.Run("RegisterFunc adds a custom function", func(t *testing.T) {
+		s := NewSpreadsheet()
+		s.RegisterFunc("DOUBLESUM", func(args []int) (int, error) {
+			sum := 0
+			for _, a := range args {
+				sum += a
+			}
+			return sum * 2, nil
+		})
+		assert.NoError(t, s.SetCellValue("A1", 1))
+		assert.NoError(t, s.SetCellValue("A2", 2))
+		assert.NoError(t, s.SetCellValue("B1", "=DOUBLESUM(A1:A2)"))
+		assertCellValue(t, s, "B1", IntVal{6})
+	})
+}
+
+func TestSpreadsheet_typedValues(t *testing.T) {
+	t.Run("float arithmetic", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 1.5))
+		assert.NoError(t, s.SetCellValue("A2", 2.5))
+		assert.NoError(t, s.SetCellValue("B1", "=A1+A2"))
+		assertCellValue(t, s, "B1", FloatVal{4})
+	})
+
+	t.Run("int and float promote to float", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 1))
+		assert.NoError(t, s.SetCellValue("A2", 0.5))
+		assert.NoError(t, s.SetCellValue("B1", "=A1+A2"))
+		assertCellValue(t, s, "B1", FloatVal{1.5})
+	})
+
+	t.Run("string literal", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", `="hello"`))
+		assertCellValue(t, s, "A1", StringVal{"hello"})
+	})
+
+	t.Run("bool literal", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", true))
+		assertCellValue(t, s, "A1", BoolVal{true})
+	})
+
+	t.Run("comparison yields a bool", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", 5))
+		assert.NoError(t, s.SetCellValue("B1", "=A1>3"))
+		assertCellValue(t, s, "B1", BoolVal{true})
+	})
+
+	t.Run("string comparison", func(t *testing.T) {
+		s := NewSpreadsheet()
+		assert.NoError(t, s.SetCellValue("A1", `="foo"=="foo"`))
+		assertCellValue(t, s, "A1", BoolVal{true})
 	})
 }
 
-func assertCellValue(t *testing.T, s *Spreadsheet, cellID string, expectedValue int) {
+func assertCellValue(t *testing.T, s *Spreadsheet, cellID string, expected Value) {
 	t.Helper()
 	val, err := s.GetCellValue(cellID)
 	assert.NoError(t, err)
-	assert.EqualValues(t, expectedValue, val)
+	assert.EqualValues(t, expected, val)
 }
 
 func TestSpreadsheet_eval(t *testing.T) {
@@ -93,7 +266,7 @@ func TestSpreadsheet_eval(t *testing.T) {
 		name     string
 		sheet    *Spreadsheet
 		expr     Expr
-		expected int
+		expected Value
 	}{
 		{
 			name:  "basic",
@@ -102,7 +275,7 @@ func TestSpreadsheet_eval(t *testing.T) {
 				add(cellRef(0, 0), cellRef(0, 1)),
 				add(cellRef(1, 0), cellRef(1, 1)),
 			),
-			expected: 10,
+			expected: IntVal{10},
 		},
 	}
 	for _, tt := range tests {
@@ -120,7 +293,7 @@ func spreadsheet(input [][]any) *Spreadsheet {
 			cid := CellID{row: r, column: c}
 			switch val := input[r][c].(type) {
 			case int:
-				result.cells[cid] = &Cell{currValue: val}
+				result.cells[cid] = &Cell{currValue: IntVal{val}}
 			case Expr:
 				result.cells[cid] = &Cell{expr: &val}
 			}